@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/imageformats/index"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/platforms"
+	"github.com/urfave/cli"
+)
+
+var imagesIndexCommand = cli.Command{
+	Name:  "index",
+	Usage: "create and inspect multi-platform image indexes",
+	Subcommands: []cli.Command{
+		imagesIndexCreateCommand,
+		imagesIndexUnpackCommand,
+	},
+}
+
+var imagesIndexCreateCommand = cli.Command{
+	Name:      "create",
+	Usage:     "combine existing images into a multi-platform index",
+	ArgsUsage: "[flags] <ref> <child-ref> [<child-ref>, ...]",
+	Description: `Create assembles an index (manifest list) out of images already present
+in the content store, one contributed by each <child-ref>, and stores the
+result under <ref>.
+
+This is the "manifest create" / "manifest push" workflow: import several
+per-arch tarballs with "ctr images import", then combine them into one
+multi-arch image with this command. Each <child-ref> is listed under the
+platform set on its manifest descriptor (e.g. imported via
+"ctr images import --filter architecture==amd64 ..."); a <child-ref>
+without one is listed under the current host's default platform.
+`,
+	Action: func(clicontext *cli.Context) error {
+		var (
+			ref       = clicontext.Args().First()
+			childRefs = clicontext.Args().Tail()
+		)
+		if ref == "" || len(childRefs) == 0 {
+			return fmt.Errorf("please provide a ref and at least one child-ref")
+		}
+		client, ctx, cancel, err := newClient(clicontext)
+		if err != nil {
+			return err
+		}
+		defer cancel()
+
+		var children []index.Child
+		for _, childRef := range childRefs {
+			img, err := client.GetImage(ctx, childRef)
+			if err != nil {
+				return err
+			}
+			desc := img.Target()
+			platform := platforms.DefaultSpec()
+			if desc.Platform != nil {
+				platform = *desc.Platform
+			}
+			children = append(children, index.Child{
+				Descriptor: desc,
+				Platform:   platform,
+			})
+		}
+
+		desc, err := index.Create(ctx, client.ContentStore(), children)
+		if err != nil {
+			return err
+		}
+
+		if _, err := client.ImageService().Create(ctx, images.Image{
+			Name:   ref,
+			Target: *desc,
+		}); err != nil {
+			return err
+		}
+		fmt.Println(ref)
+		return nil
+	},
+}
+
+var imagesIndexUnpackCommand = cli.Command{
+	Name:      "unpack",
+	Usage:     "unpack the manifest in an index matching the current platform",
+	ArgsUsage: "[flags] <ref>",
+	Description: `Unpack resolves ref (an index created by "images index create", or any
+other multi-platform image) to the first manifest in it, in index order,
+whose platform the current host's default matcher accepts (manifests are
+not ranked against each other), then unpacks that manifest's layers into the
+given snapshotter, the same way importing and unpacking a single-platform
+image would.
+`,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "snapshotter",
+			Usage: "snapshotter name. Empty value stands for the default value.",
+		},
+	},
+	Action: func(clicontext *cli.Context) error {
+		ref := clicontext.Args().First()
+		if ref == "" {
+			return fmt.Errorf("please provide a ref")
+		}
+		client, ctx, cancel, err := newClient(clicontext)
+		if err != nil {
+			return err
+		}
+		defer cancel()
+
+		img, err := client.GetImage(ctx, ref)
+		if err != nil {
+			return err
+		}
+
+		matcher := platforms.Default()
+		desc, err := index.SelectManifest(ctx, client.ContentStore(), img.Target(), matcher)
+		if err != nil {
+			return err
+		}
+
+		manifestRef := ref + "@" + desc.Digest.String()
+		if _, err := client.ImageService().Create(ctx, images.Image{
+			Name:   manifestRef,
+			Target: *desc,
+		}); err != nil && !errdefs.IsAlreadyExists(err) {
+			return err
+		}
+		manifestImg, err := client.GetImage(ctx, manifestRef)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("unpacking %s (%s)...", ref, desc.Digest)
+		if err := manifestImg.Unpack(ctx, clicontext.String("snapshotter")); err != nil {
+			return err
+		}
+		fmt.Println("done")
+		return nil
+	},
+}
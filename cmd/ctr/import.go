@@ -34,6 +34,12 @@ Supported filters:
 
 - docker.v1:
 -- index:        manifest list index (e.g. "0")
+-- repoTag:      matched against any element of RepoTags (e.g. "busybox:latest")
+-- architecture: e.g. "amd64"
+-- os:           e.g. "linux"
+-- variant:      e.g. "v7" (when architecture is "arm")
+With no filter, a docker.v1 archive whose entries all share a RepoTag is
+imported as a manifest list combining every entry.
 
 Specifying filter is mandatory if an image contains multiple objects.
 If filtered result contains multiple items, it results in ErrAmbiguous.
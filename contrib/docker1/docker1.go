@@ -1,6 +1,8 @@
 // Package docker1 provides the importer for legacy Docker save/load spec.
 package docker1
 
+import ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
 // manifest is an entry in manifest.json.
 type manifest struct {
 	Config   string
@@ -9,3 +11,12 @@ type manifest struct {
 	// Parent is unsupported
 	Parent string
 }
+
+// manifestList is a Docker v2.2 manifest list (aka "fat manifest"), produced
+// when manifest.json has more than one entry, every entry shares a RepoTag
+// and no filter is given (a multi-arch `docker save`).
+type manifestList struct {
+	SchemaVersion int                  `json:"schemaVersion"`
+	MediaType     string               `json:"mediaType"`
+	Manifests     []ocispec.Descriptor `json:"manifests"`
+}
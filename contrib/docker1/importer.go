@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"io"
 	"io/ioutil"
+	"os"
 	"strings"
 
 	"github.com/containerd/containerd/content"
@@ -27,8 +28,21 @@ import (
 // Also, the current implementation assumes the implicit file name convention,
 // which is not explicitly documented in the spec. (e.g. deadbeef/layer.tar)
 //
+// When manifest.json has more than one entry, filter is empty, and every
+// entry shares at least one RepoTag (the signal that they are per-platform
+// variants of the same logical image, e.g. a multi-arch `docker save`),
+// Import produces a Docker manifest list combining a schema2 manifest
+// synthesized from every entry. A multi-entry manifest.json whose entries
+// don't share a RepoTag is just several unrelated images in one archive, so
+// it is treated like any other ambiguous multi-entry archive: filtering is
+// required.
+//
 // Supported filters:
 // - index:        manifest list index (e.g. "0")
+// - repoTag:      matched against any element of RepoTags (e.g. "busybox:latest")
+// - architecture: e.g. "amd64"
+// - os:           e.g. "linux"
+// - variant:      e.g. "v7" (when architecture is "arm")
 var Importer imageformats.Importer = &importer{}
 
 type importer struct {
@@ -51,13 +65,25 @@ type imageConfig struct {
 	img  ocispec.Image
 }
 
-func (importer *importer) Import(ctx context.Context, store content.Store, reader io.Reader, filter string) (*ocispec.Descriptor, error) {
+func (importer *importer) Import(ctx context.Context, store content.Store, reader io.Reader, filter string, opts ...imageformats.ImportOpt) (*ocispec.Descriptor, error) {
+	var iopts imageformats.ImportOpts
+	for _, o := range opts {
+		o(&iopts)
+	}
+
 	tr := tar.NewReader(reader)
 	var (
-		mfst    *manifest
-		layers  = make(map[string]ocispec.Descriptor, 0) // key: filename (deadbeeddeadbeef/layer.tar)
-		configs = make(map[string]imageConfig, 0)        // key: filename (deadbeeddeadbeef.json)
+		mfsts      []manifest
+		layers     = make(map[string]ocispec.Descriptor, 0) // key: filename (deadbeeddeadbeef/layer.tar)
+		layerFiles = make(map[string]*os.File, 0)           // key: filename, buffered until its digest is known
+		configs    = make(map[string]imageConfig, 0)        // key: filename (deadbeeddeadbeef.json)
 	)
+	defer func() {
+		for _, f := range layerFiles {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
 
 	for {
 		hdr, err := tr.Next()
@@ -71,22 +97,25 @@ func (importer *importer) Import(ctx context.Context, store content.Store, reade
 			continue
 		}
 		if hdr.Name == "manifest.json" {
-			mfst, err = onUntarManifestJSON(tr, filter)
+			mfsts, err = onUntarManifestJSON(tr)
 			if err != nil {
 				return nil, err
 			}
 			continue
 		}
 		if isLayerTar(hdr.Name) {
-			desc, err := onUntarLayerTar(ctx, tr, store, hdr.Name, hdr.Size)
+			// the layer's expected digest is its uncompressed DiffID, which
+			// lives in a config file that may not have been seen yet, so
+			// buffer it to disk and commit it once every config is parsed.
+			f, err := bufferLayerTar(tr, hdr.Size)
 			if err != nil {
 				return nil, err
 			}
-			layers[hdr.Name] = *desc
+			layerFiles[hdr.Name] = f
 			continue
 		}
 		if isDotJSON(hdr.Name) {
-			c, err := onUntarDotJSON(ctx, tr, store, hdr.Name, hdr.Size)
+			c, err := onUntarDotJSON(ctx, tr, store, hdr.Name, hdr.Size, iopts.Progress)
 			if err != nil {
 				return nil, err
 			}
@@ -94,8 +123,47 @@ func (importer *importer) Import(ctx context.Context, store content.Store, reade
 			continue
 		}
 	}
-	if mfst == nil {
-		return nil, errors.Errorf("no manifest found for filter %q", filter)
+	if mfsts == nil {
+		return nil, errors.Errorf("no manifest.json found in archive")
+	}
+
+	for i := range mfsts {
+		config, ok := configs[mfsts[i].Config]
+		if !ok {
+			continue // reported once the manifest referencing it is selected
+		}
+		for j, name := range mfsts[i].Layers {
+			if _, done := layers[name]; done {
+				continue
+			}
+			f, ok := layerFiles[name]
+			if !ok {
+				continue
+			}
+			var expected digest.Digest
+			if j < len(config.img.RootFS.DiffIDs) {
+				expected = config.img.RootFS.DiffIDs[j]
+			}
+			desc, err := commitLayerTar(ctx, store, f, name, expected, iopts.Progress)
+			if err != nil {
+				return nil, err
+			}
+			layers[name] = *desc
+			delete(layerFiles, name)
+		}
+	}
+
+	if filter == "" && len(mfsts) > 1 && sharedRepoTag(mfsts) {
+		return writeDockerManifestList(ctx, store, mfsts, configs, layers)
+	}
+
+	platforms := make(map[string]*ocispec.Platform, len(configs))
+	for name, c := range configs {
+		platforms[name] = platformFromImageConfig(c.img)
+	}
+	mfst, err := filterManifests(mfsts, filter, platforms)
+	if err != nil {
+		return nil, err
 	}
 	config, ok := configs[mfst.Config]
 	if !ok {
@@ -105,7 +173,90 @@ func (importer *importer) Import(ctx context.Context, store content.Store, reade
 	if err != nil {
 		return nil, err
 	}
-	return writeDockerSchema2Manifest(ctx, store, schema2Manifest, config.img.Architecture, config.img.OS)
+	return writeDockerSchema2Manifest(ctx, store, schema2Manifest, platformFromImageConfig(config.img))
+}
+
+// sharedRepoTag reports whether every entry in mfsts lists at least one
+// RepoTag in common, the only signal the Docker Image Spec gives that
+// several manifest.json entries are per-platform variants of one logical
+// image rather than unrelated images that happen to share an archive.
+func sharedRepoTag(mfsts []manifest) bool {
+	if len(mfsts) == 0 {
+		return false
+	}
+	common := make(map[string]bool, len(mfsts[0].RepoTags))
+	for _, tag := range mfsts[0].RepoTags {
+		common[tag] = true
+	}
+	for _, mfst := range mfsts[1:] {
+		next := make(map[string]bool, len(mfst.RepoTags))
+		for _, tag := range mfst.RepoTags {
+			if common[tag] {
+				next[tag] = true
+			}
+		}
+		common = next
+		if len(common) == 0 {
+			return false
+		}
+	}
+	return len(common) > 0
+}
+
+// writeDockerManifestList synthesizes a schema2 manifest from every entry in
+// mfsts and combines them into a single Docker manifest list descriptor,
+// mirroring the multi-arch index that `docker save` produces for a fat
+// manifest.
+func writeDockerManifestList(ctx context.Context, store content.Store, mfsts []manifest, configs map[string]imageConfig, layers map[string]ocispec.Descriptor) (*ocispec.Descriptor, error) {
+	list := manifestList{
+		SchemaVersion: 2,
+		MediaType:     images.MediaTypeDockerSchema2ManifestList,
+	}
+	for i := range mfsts {
+		mfst := &mfsts[i]
+		config, ok := configs[mfst.Config]
+		if !ok {
+			return nil, errors.Errorf("image config %q not found", mfst.Config)
+		}
+		schema2Manifest, err := makeDockerSchema2Manifest(mfst, config, layers)
+		if err != nil {
+			return nil, err
+		}
+		desc, err := writeDockerSchema2Manifest(ctx, store, schema2Manifest, platformFromImageConfig(config.img))
+		if err != nil {
+			return nil, err
+		}
+		list.Manifests = append(list.Manifests, *desc)
+	}
+
+	listBytes, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+	listDigest := digest.FromBytes(listBytes)
+	if err := content.WriteBlob(ctx, store, "unknown-"+listDigest.String(), bytes.NewReader(listBytes), int64(len(listBytes)), listDigest); err != nil {
+		return nil, err
+	}
+	return &ocispec.Descriptor{
+		MediaType: images.MediaTypeDockerSchema2ManifestList,
+		Digest:    listDigest,
+		Size:      int64(len(listBytes)),
+	}, nil
+}
+
+// platformFromImageConfig derives the platform a schema2 manifest's
+// descriptor should advertise from its image config, or nil if the config
+// carries no platform information.
+func platformFromImageConfig(img ocispec.Image) *ocispec.Platform {
+	if img.Architecture == "" && img.OS == "" {
+		return nil
+	}
+	return &ocispec.Platform{
+		Architecture: img.Architecture,
+		OS:           img.OS,
+		OSVersion:    img.OSVersion,
+		Variant:      img.Variant,
+	}
 }
 
 func makeDockerSchema2Manifest(mfst *manifest, config imageConfig, layers map[string]ocispec.Descriptor) (*ocispec.Manifest, error) {
@@ -125,7 +276,7 @@ func makeDockerSchema2Manifest(mfst *manifest, config imageConfig, layers map[st
 	return &manifest, nil
 }
 
-func writeDockerSchema2Manifest(ctx context.Context, store content.Store, manifest *ocispec.Manifest, arch, os string) (*ocispec.Descriptor, error) {
+func writeDockerSchema2Manifest(ctx context.Context, store content.Store, manifest *ocispec.Manifest, platform *ocispec.Platform) (*ocispec.Descriptor, error) {
 	manifestBytes, err := json.Marshal(manifest)
 	if err != nil {
 		return nil, err
@@ -140,17 +291,12 @@ func writeDockerSchema2Manifest(ctx context.Context, store content.Store, manife
 		MediaType: images.MediaTypeDockerSchema2Manifest,
 		Digest:    manifestDigest,
 		Size:      int64(len(manifestBytes)),
-	}
-	if arch != "" || os != "" {
-		desc.Platform = &ocispec.Platform{
-			Architecture: arch,
-			OS:           os,
-		}
+		Platform:  platform,
 	}
 	return desc, nil
 }
 
-func onUntarManifestJSON(r io.Reader, filter string) (*manifest, error) {
+func onUntarManifestJSON(r io.Reader) ([]manifest, error) {
 	// name: "manifest.json"
 	b, err := ioutil.ReadAll(r)
 	if err != nil {
@@ -160,60 +306,132 @@ func onUntarManifestJSON(r io.Reader, filter string) (*manifest, error) {
 	if err := json.Unmarshal(b, &mfsts); err != nil {
 		return nil, err
 	}
-	return filterManifests(mfsts, filter)
+	return mfsts, nil
 }
 
-func onUntarLayerTar(ctx context.Context, r io.Reader, store content.Store, name string, size int64) (*ocispec.Descriptor, error) {
-	// name is like "deadbeeddeadbeef/layer.tar" ( guaranteed by isLayerTar() )
-	split := strings.Split(name, "/")
-	// note: split[0] is not expected digest here
-	cw, err := store.Writer(ctx, "unknown-"+split[0], size, "")
+// bufferLayerTar copies a "deadbeeddeadbeef/layer.tar" entry to a temp file,
+// since its expected digest (the owning image config's RootFS.DiffIDs) may
+// not be known until a config appearing later in the archive is parsed.
+func bufferLayerTar(r io.Reader, size int64) (*os.File, error) {
+	f, err := ioutil.TempFile("", "docker1-import-layer-")
 	if err != nil {
 		return nil, err
 	}
-	defer cw.Close()
-	_, err = io.Copy(cw, r)
-	if err != nil {
+	if _, err := io.CopyN(f, r, size); err != nil {
+		f.Close()
+		os.Remove(f.Name())
 		return nil, err
 	}
-	if err = cw.Commit(ctx, size, ""); err != nil {
+	return f, nil
+}
+
+// commitLayerTar writes f's contents to the content store under expected
+// (or, if the owning config's DiffID could not be determined, under a ref
+// derived from the layer's own archive directory name) and removes the
+// temp file.
+func commitLayerTar(ctx context.Context, store content.Store, f *os.File, name string, expected digest.Digest, progress func(ocispec.Descriptor, int64, int64)) (*ocispec.Descriptor, error) {
+	defer f.Close()
+	defer os.Remove(f.Name())
+
+	info, err := f.Stat()
+	if err != nil {
 		return nil, err
 	}
-	desc := ocispec.Descriptor{
-		MediaType: images.MediaTypeDockerSchema2Layer,
-		Size:      size,
+	size := info.Size()
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
 	}
-	desc.Digest = cw.Digest()
-	return &desc, nil
-}
 
-func onUntarDotJSON(ctx context.Context, r io.Reader, store content.Store, name string, size int64) (*imageConfig, error) {
-	config := imageConfig{}
-	config.desc.MediaType = images.MediaTypeDockerSchema2Config
-	config.desc.Size = size
-	// name is like "deadbeeddeadbeef.json" ( guaranteed by is DotJSON() )
-	cw, err := store.Writer(ctx, "unknown-"+name, size, "")
+	ref := "unknown-" + expected.String()
+	if expected == "" {
+		// name is like "deadbeeddeadbeef/layer.tar" (guaranteed by
+		// isLayerTar()); its directory is unique per layer in the archive,
+		// unlike a shared placeholder, so concurrent unresolved layers don't
+		// contend on the same content-store writer ref.
+		ref = "unknown-" + strings.Split(name, "/")[0]
+	}
+	desc := ocispec.Descriptor{MediaType: images.MediaTypeDockerSchema2Layer, Size: size}
+	dgst, err := writeBlobWithProgress(ctx, store, ref, f, size, expected, desc, progress)
 	if err != nil {
 		return nil, err
 	}
-	defer cw.Close()
-	var buf bytes.Buffer
-	tr := io.TeeReader(r, &buf)
-	_, err = io.Copy(cw, tr)
+	desc.Digest = dgst
+	return &desc, nil
+}
+
+func onUntarDotJSON(ctx context.Context, r io.Reader, store content.Store, name string, size int64, progress func(ocispec.Descriptor, int64, int64)) (*imageConfig, error) {
+	b, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
-	if err = cw.Commit(ctx, size, ""); err != nil {
+
+	// name is like "deadbeeddeadbeef.json" (guaranteed by isDotJSON()); the
+	// Docker Image Spec names config files by their own sha256 digest, so
+	// it can be verified and deduped just like an OCI blob.
+	expected := digest.NewDigestFromHex(digest.SHA256.String(), strings.TrimSuffix(name, ".json"))
+	desc := ocispec.Descriptor{MediaType: images.MediaTypeDockerSchema2Config, Digest: expected, Size: size}
+	if _, err := writeBlobWithProgress(ctx, store, "unknown-"+name, bytes.NewReader(b), size, expected, desc, progress); err != nil {
 		return nil, err
 	}
-	config.desc.Digest = cw.Digest()
-	if err := json.Unmarshal(buf.Bytes(), &config.img); err != nil {
+
+	config := imageConfig{desc: desc}
+	if err := json.Unmarshal(b, &config.img); err != nil {
 		return nil, err
 	}
 	return &config, nil
 }
 
-func filterManifests(manifests []manifest, filter string) (*manifest, error) {
+// writeBlobWithProgress writes size bytes from r to store under ref,
+// verifying against expected as they stream in (if expected is set), and
+// reporting progress for desc. A blob already present in the store is
+// skipped entirely, with progress (if set) reported as fully done.
+func writeBlobWithProgress(ctx context.Context, store content.Store, ref string, r io.Reader, size int64, expected digest.Digest, desc ocispec.Descriptor, progress func(desc ocispec.Descriptor, offset, total int64)) (digest.Digest, error) {
+	cw, err := store.Writer(ctx, ref, size, expected)
+	if err != nil {
+		if errdefs.IsAlreadyExists(err) {
+			if progress != nil {
+				progress(desc, size, size)
+			}
+			return expected, nil
+		}
+		return "", err
+	}
+	defer cw.Close()
+
+	w := io.Writer(cw)
+	if progress != nil {
+		w = &progressWriter{w: cw, desc: desc, total: size, report: progress}
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return "", err
+	}
+	if err := cw.Commit(ctx, size, expected); err != nil {
+		return "", err
+	}
+	return cw.Digest(), nil
+}
+
+// progressWriter reports cumulative bytes written for desc after every
+// Write, so a caller can drive a progress bar for a single blob.
+type progressWriter struct {
+	w      io.Writer
+	desc   ocispec.Descriptor
+	offset int64
+	total  int64
+	report func(desc ocispec.Descriptor, offset, total int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.offset += int64(n)
+	p.report(p.desc, p.offset, p.total)
+	return n, err
+}
+
+// filterManifests returns the single manifest.json entry matching filter.
+// platforms, keyed by manifest.Config, supplies the architecture/os/variant
+// values resolved from each entry's image config.
+func filterManifests(manifests []manifest, filter string, platforms map[string]*ocispec.Platform) (*manifest, error) {
 	f, err := filters.Parse(filter)
 	if err != nil {
 		return nil, err
@@ -223,12 +441,7 @@ func filterManifests(manifests []manifest, filter string) (*manifest, error) {
 		if m.Parent != "" {
 			return nil, errors.Errorf("manifest.Parent (%q) is not supported", m.Parent)
 		}
-		adapter := imageAdapter{
-			index:    i,
-			manifest: &m,
-		}
-		ok := f.Match(adaptImage(adapter))
-		if ok {
+		if manifestMatches(f, i, &m, platforms[m.Config]) {
 			matched = append(matched, m)
 		}
 	}
@@ -241,9 +454,29 @@ func filterManifests(manifests []manifest, filter string) (*manifest, error) {
 	return &matched[0], nil
 }
 
+// manifestMatches reports whether m satisfies f. repoTag is checked against
+// every element of m.RepoTags in turn, since a filters.Adaptor can only
+// expose one value per field per call.
+func manifestMatches(f filters.Filter, index int, m *manifest, platform *ocispec.Platform) bool {
+	base := imageAdapter{index: index, manifest: m, platform: platform}
+	if f.Match(adaptImage(base)) {
+		return true
+	}
+	for _, tag := range m.RepoTags {
+		withTag := base
+		withTag.repoTag = tag
+		if f.Match(adaptImage(withTag)) {
+			return true
+		}
+	}
+	return false
+}
+
 type imageAdapter struct {
 	index    int
 	manifest *manifest
+	platform *ocispec.Platform
+	repoTag  string
 }
 
 func adaptImage(obj imageAdapter) filters.Adaptor {
@@ -251,10 +484,23 @@ func adaptImage(obj imageAdapter) filters.Adaptor {
 		if len(fieldpath) == 0 {
 			return "", false
 		}
-		// TODO: support "repoTag" (note: manifest.RepoTags can have >= 0 items)
 		switch fieldpath[0] {
 		case "index":
 			return strconv.Itoa(obj.index), true
+		case "repoTag":
+			return obj.repoTag, obj.repoTag != ""
+		case "architecture":
+			if obj.platform != nil && obj.platform.Architecture != "" {
+				return obj.platform.Architecture, true
+			}
+		case "os":
+			if obj.platform != nil && obj.platform.OS != "" {
+				return obj.platform.OS, true
+			}
+		case "variant":
+			if obj.platform != nil && obj.platform.Variant != "" {
+				return obj.platform.Variant, true
+			}
 		}
 		return "", false
 	})
@@ -0,0 +1,92 @@
+package docker1
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/containerd/containerd/internal/contenttest"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+// buildDockerSave tars up a single-manifest "docker save" archive with one
+// layer, so commitLayerTar's digest check against diffID can be exercised
+// with a controlled mismatch (or match).
+func buildDockerSave(t *testing.T, diffID digest.Digest, layerBytes []byte) []byte {
+	t.Helper()
+
+	img := ocispec.Image{
+		RootFS: ocispec.RootFS{Type: "layers", DiffIDs: []digest.Digest{diffID}},
+	}
+	configBytes, err := json.Marshal(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configName := digest.FromBytes(configBytes).Encoded() + ".json"
+
+	mfsts := []manifest{
+		{
+			Config:   configName,
+			RepoTags: []string{"example:latest"},
+			Layers:   []string{"0000000000000000000000000000000000000000000000000000000000000000/layer.tar"},
+		},
+	}
+	mfstsBytes, err := json.Marshal(mfsts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	writeEntry := func(name string, b []byte) {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0444, Size: int64(len(b))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeEntry("manifest.json", mfstsBytes)
+	writeEntry(configName, configBytes)
+	writeEntry("0000000000000000000000000000000000000000000000000000000000000000/layer.tar", layerBytes)
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImportRejectsMismatchedLayerDigest(t *testing.T) {
+	layerBytes := []byte("totally-legitimate-layer-contents")
+	wrongDiffID := digest.FromBytes([]byte("not-the-layer"))
+
+	archive := buildDockerSave(t, wrongDiffID, layerBytes)
+	store := contenttest.NewStore()
+	_, err := Importer.Import(context.Background(), store, bytes.NewReader(archive), "")
+	assert.Error(t, err)
+}
+
+func TestImportIsIdempotent(t *testing.T) {
+	layerBytes := []byte("totally-legitimate-layer-contents")
+	diffID := digest.FromBytes(layerBytes)
+
+	store := contenttest.NewStore()
+
+	archive := buildDockerSave(t, diffID, layerBytes)
+	desc1, err := Importer.Import(context.Background(), store, bytes.NewReader(archive), "")
+	assert.Nil(t, err)
+
+	blobCount := store.Len()
+
+	// Re-importing the same archive should be a no-op: every blob it
+	// produces already exists under its expected digest, so the writer for
+	// each one returns errdefs.ErrAlreadyExists and is skipped.
+	archive2 := buildDockerSave(t, diffID, layerBytes)
+	desc2, err := Importer.Import(context.Background(), store, bytes.NewReader(archive2), "")
+	assert.Nil(t, err)
+	assert.Equal(t, desc1.Digest, desc2.Digest)
+	assert.Equal(t, blobCount, store.Len())
+}
@@ -0,0 +1,124 @@
+package docker1
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/internal/contenttest"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// dockerSaveEntry is one manifest.json entry (plus its config and layer) to
+// be combined into a multi-entry "docker save" archive by buildDockerSaveMulti.
+type dockerSaveEntry struct {
+	repoTags []string
+	arch     string
+	layer    []byte
+}
+
+// layerDirName returns a 64-hex-char directory name for the i-th layer in a
+// test archive (unique for i < 16), mirroring the "deadbeef.../layer.tar" layout
+// `docker save` produces.
+func layerDirName(i int) string {
+	const hex = "0123456789abcdef"
+	b := []byte("0000000000000000000000000000000000000000000000000000000000000000")[:64]
+	b[63] = hex[i%16]
+	return string(b)
+}
+
+func buildDockerSaveMulti(t *testing.T, entries []dockerSaveEntry) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	writeEntry := func(name string, b []byte) {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0444, Size: int64(len(b))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type built struct {
+		configName, layerName string
+		configBytes           []byte
+	}
+	var mfsts []manifest
+	var entriesBuilt []built
+	for i, e := range entries {
+		diffID := digest.FromBytes(e.layer)
+		img := ocispec.Image{
+			Platform: ocispec.Platform{Architecture: e.arch, OS: "linux"},
+			RootFS:   ocispec.RootFS{Type: "layers", DiffIDs: []digest.Digest{diffID}},
+		}
+		configBytes, err := json.Marshal(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		configName := digest.FromBytes(configBytes).Encoded() + ".json"
+		layerName := layerDirName(i) + "/layer.tar"
+
+		entriesBuilt = append(entriesBuilt, built{configName: configName, layerName: layerName, configBytes: configBytes})
+		mfsts = append(mfsts, manifest{
+			Config:   configName,
+			RepoTags: e.repoTags,
+			Layers:   []string{layerName},
+		})
+	}
+
+	mfstsBytes, err := json.Marshal(mfsts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeEntry("manifest.json", mfstsBytes)
+
+	for i, b := range entriesBuilt {
+		writeEntry(b.configName, b.configBytes)
+		writeEntry(b.layerName, entries[i].layer)
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImportMultiEntryWithSharedRepoTagProducesManifestList(t *testing.T) {
+	entries := []dockerSaveEntry{
+		{repoTags: []string{"example:latest", "example:v1"}, arch: "amd64", layer: []byte("amd64-layer")},
+		{repoTags: []string{"example:latest"}, arch: "arm64", layer: []byte("arm64-layer")},
+	}
+	archive := buildDockerSaveMulti(t, entries)
+
+	store := contenttest.NewStore()
+	desc, err := Importer.Import(context.Background(), store, bytes.NewReader(archive), "")
+	assert.Nil(t, err)
+	assert.Equal(t, images.MediaTypeDockerSchema2ManifestList, desc.MediaType)
+
+	b, ok := store.Blob(desc.Digest)
+	assert.True(t, ok)
+	var list manifestList
+	assert.Nil(t, json.Unmarshal(b, &list))
+	assert.Len(t, list.Manifests, 2)
+}
+
+func TestImportMultiEntryWithoutSharedRepoTagIsAmbiguous(t *testing.T) {
+	entries := []dockerSaveEntry{
+		{repoTags: []string{"example:latest"}, arch: "amd64", layer: []byte("amd64-layer")},
+		{repoTags: []string{"unrelated:latest"}, arch: "arm64", layer: []byte("arm64-layer")},
+	}
+	archive := buildDockerSaveMulti(t, entries)
+
+	store := contenttest.NewStore()
+	_, err := Importer.Import(context.Background(), store, bytes.NewReader(archive), "")
+	assert.Equal(t, errdefs.ErrAmbiguous, errors.Cause(err))
+}
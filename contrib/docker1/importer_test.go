@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/containerd/containerd/errdefs"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
@@ -23,6 +24,18 @@ var exampleManifests = []manifest{
 	},
 }
 
+var examplePlatforms = map[string]*ocispec.Platform{
+	"0000000000000000000000000000000000000000000000000000000000000000.json": {
+		Architecture: "amd64",
+		OS:           "linux",
+	},
+	"0000000000000000000000000000000000000000000000000000000000000001.json": {
+		Architecture: "arm",
+		OS:           "linux",
+		Variant:      "v7",
+	},
+}
+
 func TestImporterFilter(t *testing.T) {
 	testCases := []struct {
 		filter      string
@@ -33,14 +46,25 @@ func TestImporterFilter(t *testing.T) {
 			filter:   "index==1",
 			expected: "0000000000000000000000000000000000000000000000000000000000000001.json",
 		},
-		// repoTag filter is not supported yet
 		{
 			filter:      "",
 			expectedErr: errdefs.ErrAmbiguous,
 		},
+		{
+			filter:   "repoTag==foo:bar",
+			expected: "0000000000000000000000000000000000000000000000000000000000000001.json",
+		},
+		{
+			filter:   "architecture==arm,variant==v7",
+			expected: "0000000000000000000000000000000000000000000000000000000000000001.json",
+		},
+		{
+			filter:      "architecture==amd64,os==windows",
+			expectedErr: errdefs.ErrNotFound,
+		},
 	}
 	for _, tc := range testCases {
-		filtered, err := filterManifests(exampleManifests, tc.filter)
+		filtered, err := filterManifests(exampleManifests, tc.filter, examplePlatforms)
 		if tc.expectedErr != nil {
 			assert.Equal(t, tc.expectedErr, errors.Cause(err), tc.filter)
 		} else {
@@ -81,7 +105,7 @@ func TestImporterFilterWithSingleManifest(t *testing.T) {
 		},
 	}
 	for _, tc := range testCases {
-		filtered, err := filterManifests(x, tc.filter)
+		filtered, err := filterManifests(x, tc.filter, nil)
 
 		if tc.expectedErr != nil {
 			assert.Equal(t, tc.expectedErr, errors.Cause(err), tc.filter)
@@ -0,0 +1,48 @@
+// Package imageformats defines the interfaces implemented by the
+// importers and exporters in its subpackages (e.g. imageformats/oci).
+package imageformats
+
+import (
+	"context"
+	"io"
+
+	"github.com/containerd/containerd/content"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Importer converts an external image archive format into content stored
+// in the content store, returning the descriptor of the image selected by
+// filter (or of a manifest list / index, where the importer combines several
+// images of the archive into one).
+type Importer interface {
+	// Import imports an image from a tar stream.
+	Import(ctx context.Context, store content.Store, reader io.Reader, filter string, opts ...ImportOpt) (*ocispec.Descriptor, error)
+}
+
+// ImportOpts holds the options settable through ImportOpt.
+type ImportOpts struct {
+	// Progress, if set, is called as each blob is streamed into the
+	// content store, reporting how many of its bytes have been written
+	// so far. It may be called with offset == total once a blob that was
+	// already present in the store is skipped entirely.
+	Progress func(desc ocispec.Descriptor, offset, total int64)
+}
+
+// ImportOpt configures an Import call.
+type ImportOpt func(*ImportOpts)
+
+// WithProgress registers a per-blob progress callback, letting a caller
+// drive a progress bar for long-running imports.
+func WithProgress(fn func(desc ocispec.Descriptor, offset, total int64)) ImportOpt {
+	return func(o *ImportOpts) {
+		o.Progress = fn
+	}
+}
+
+// Exporter walks a manifest or index descriptor already present in the
+// content store and serializes it into an external image archive format,
+// the counterpart of Importer.
+type Exporter interface {
+	// Export writes desc, and everything it references, to writer.
+	Export(ctx context.Context, store content.Store, desc ocispec.Descriptor, writer io.Writer) error
+}
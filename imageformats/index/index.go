@@ -0,0 +1,105 @@
+// Package index builds OCI image indexes out of manifests already present
+// in the content store (and selects from OCI indexes and Docker manifest
+// lists), the "manifest add" /
+// "manifest push" workflow: import several per-arch tarballs with
+// `ctr images import`, then assemble them into one multi-arch image under a
+// single ref.
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/platforms"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// Child is one platform-specific manifest to be combined into a new index
+// by Create.
+type Child struct {
+	Descriptor  ocispec.Descriptor
+	Platform    ocispec.Platform
+	Annotations map[string]string
+}
+
+// Create combines children into a new OCI image index and writes it to
+// store, returning the descriptor of the index. It does not write anything
+// other than the index itself; every child is expected to already be
+// present in store.
+func Create(ctx context.Context, store content.Store, children []Child) (*ocispec.Descriptor, error) {
+	if len(children) == 0 {
+		return nil, errors.New("index: at least one child is required")
+	}
+
+	idx := ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+	}
+	for _, c := range children {
+		desc := c.Descriptor
+		platform := c.Platform
+		desc.Platform = &platform
+		desc.Annotations = c.Annotations
+		idx.Manifests = append(idx.Manifests, desc)
+	}
+
+	b, err := json.Marshal(idx)
+	if err != nil {
+		return nil, err
+	}
+	dgst := digest.FromBytes(b)
+	if err := content.WriteBlob(ctx, store, "unknown-"+dgst.String(), bytes.NewReader(b), int64(len(b)), dgst); err != nil {
+		return nil, err
+	}
+	return &ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Digest:    dgst,
+		Size:      int64(len(b)),
+	}, nil
+}
+
+// SelectManifest picks the first child of desc whose platform satisfies
+// matcher; desc may be a plain manifest, in which case it is returned
+// unchanged, or an OCI index / Docker manifest list produced by Create (or
+// by docker1's fat manifest support), in which case its manifests are read
+// from provider.
+//
+// "First" is list order, not preference: with a lenient matcher (one that
+// accepts more than one platform as compatible, e.g. amd64 variants on a
+// newer CPU) more than one child can satisfy Match, and whichever was listed
+// first in the index wins, not the closest match. Callers that care about
+// ranking compatible-but-imperfect matches should use a narrow matcher like
+// platforms.Only, which only ever accepts one exact platform.
+func SelectManifest(ctx context.Context, provider content.Provider, desc ocispec.Descriptor, matcher platforms.Matcher) (*ocispec.Descriptor, error) {
+	if desc.MediaType != ocispec.MediaTypeImageIndex && desc.MediaType != images.MediaTypeDockerSchema2ManifestList {
+		return &desc, nil
+	}
+
+	ra, err := provider.ReaderAt(ctx, desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	defer ra.Close()
+	b := make([]byte, desc.Size)
+	if _, err := io.ReadFull(io.NewSectionReader(ra, 0, desc.Size), b); err != nil {
+		return nil, err
+	}
+	var idx ocispec.Index
+	if err := json.Unmarshal(b, &idx); err != nil {
+		return nil, err
+	}
+
+	for i, m := range idx.Manifests {
+		if m.Platform != nil && matcher.Match(*m.Platform) {
+			return &idx.Manifests[i], nil
+		}
+	}
+	return nil, errors.Errorf("no manifest in %s matches the current platform", desc.Digest)
+}
@@ -0,0 +1,126 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/containerd/containerd/internal/contenttest"
+	"github.com/containerd/containerd/platforms"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectManifestReturnsNonIndexUnchanged(t *testing.T) {
+	desc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Digest:    "sha256:772a956f8de2e8883b5b74dac1599eff29ee0beb09d02689a6d1805bf8487422",
+	}
+	matcher := platforms.Only(ocispec.Platform{Architecture: "amd64", OS: "linux"})
+
+	selected, err := SelectManifest(context.Background(), nil, desc, matcher)
+	assert.Nil(t, err)
+	assert.Equal(t, &desc, selected)
+}
+
+func TestSelectManifestPicksMatchingChild(t *testing.T) {
+	amd64 := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Digest:    "sha256:772a956f8de2e8883b5b74dac1599eff29ee0beb09d02689a6d1805bf8487422",
+		Platform:  &ocispec.Platform{Architecture: "amd64", OS: "linux"},
+	}
+	arm64 := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Digest:    "sha256:e5c50d48ae80fafb15b390277fc76b6f1cb6b942097cd4c5a55077af351bc28f",
+		Platform:  &ocispec.Platform{Architecture: "arm64", OS: "linux"},
+	}
+	idx := ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{amd64, arm64},
+	}
+	idxBytes, err := json.Marshal(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Digest:    digest.FromBytes(idxBytes),
+		Size:      int64(len(idxBytes)),
+	}
+	provider := contenttest.Provider{desc.Digest: idxBytes}
+
+	matcher := platforms.Only(ocispec.Platform{Architecture: "arm64", OS: "linux"})
+	selected, err := SelectManifest(context.Background(), provider, desc, matcher)
+	assert.Nil(t, err)
+	assert.Equal(t, arm64.Digest, selected.Digest)
+}
+
+func TestSelectManifestNoMatch(t *testing.T) {
+	amd64 := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Digest:    "sha256:772a956f8de2e8883b5b74dac1599eff29ee0beb09d02689a6d1805bf8487422",
+		Platform:  &ocispec.Platform{Architecture: "amd64", OS: "linux"},
+	}
+	idx := ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{amd64},
+	}
+	idxBytes, err := json.Marshal(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Digest:    digest.FromBytes(idxBytes),
+		Size:      int64(len(idxBytes)),
+	}
+	provider := contenttest.Provider{desc.Digest: idxBytes}
+
+	matcher := platforms.Only(ocispec.Platform{Architecture: "arm", OS: "linux", Variant: "v7"})
+	_, err = SelectManifest(context.Background(), provider, desc, matcher)
+	assert.NotNil(t, err)
+}
+
+func TestCreate(t *testing.T) {
+	ctx := context.Background()
+	store := contenttest.NewStore()
+
+	amd64 := Child{
+		Descriptor:  ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: "sha256:772a956f8de2e8883b5b74dac1599eff29ee0beb09d02689a6d1805bf8487422", Size: 123},
+		Platform:    ocispec.Platform{Architecture: "amd64", OS: "linux"},
+		Annotations: map[string]string{"test": "amd64"},
+	}
+	arm64 := Child{
+		Descriptor: ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: "sha256:e5c50d48ae80fafb15b390277fc76b6f1cb6b942097cd4c5a55077af351bc28f", Size: 456},
+		Platform:   ocispec.Platform{Architecture: "arm64", OS: "linux"},
+	}
+
+	desc, err := Create(ctx, store, []Child{amd64, arm64})
+	assert.Nil(t, err)
+	assert.Equal(t, ocispec.MediaTypeImageIndex, desc.MediaType)
+
+	b, ok := store.Blob(desc.Digest)
+	assert.True(t, ok, "index was not written to the store")
+
+	var idx ocispec.Index
+	assert.Nil(t, json.Unmarshal(b, &idx))
+	assert.Equal(t, 2, idx.SchemaVersion)
+	assert.Len(t, idx.Manifests, 2)
+
+	assert.Equal(t, amd64.Descriptor.Digest, idx.Manifests[0].Digest)
+	assert.Equal(t, amd64.Descriptor.Size, idx.Manifests[0].Size)
+	assert.Equal(t, amd64.Platform, *idx.Manifests[0].Platform)
+	assert.Equal(t, amd64.Annotations, idx.Manifests[0].Annotations)
+
+	assert.Equal(t, arm64.Descriptor.Digest, idx.Manifests[1].Digest)
+	assert.Equal(t, arm64.Platform, *idx.Manifests[1].Platform)
+}
+
+func TestCreateRequiresAtLeastOneChild(t *testing.T) {
+	_, err := Create(context.Background(), contenttest.NewStore(), nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,269 @@
+package oci
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/imageformats"
+	"github.com/containerd/containerd/images"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+type v1Exporter struct {
+	platforms []ocispec.Platform
+}
+
+// V1ExporterOpt configures a V1Exporter returned by NewV1Exporter.
+type V1ExporterOpt func(*v1Exporter) error
+
+// WithPlatform restricts the exported layout to manifests matching one of
+// the given platforms. It may be supplied more than once. If it is never
+// supplied, every manifest reachable from the exported descriptor is
+// included.
+func WithPlatform(platform ocispec.Platform) V1ExporterOpt {
+	return func(e *v1Exporter) error {
+		e.platforms = append(e.platforms, platform)
+		return nil
+	}
+}
+
+// NewV1Exporter returns an Exporter which writes an OCI Image Spec layout,
+// the counterpart of V1Importer.
+func NewV1Exporter(opts ...V1ExporterOpt) (imageformats.Exporter, error) {
+	e := &v1Exporter{}
+	for _, opt := range opts {
+		if err := opt(e); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Export walks desc (a manifest or an index/manifest-list) and writes an
+// OCI image layout tar to writer: the "oci-layout" marker file, an
+// "index.json" listing the selected manifests, and every blob they
+// reference under "blobs/<algo>/<digest>". Any referrer manifests attached
+// to a selected manifest (signatures, SBOMs, attestations; see Referrers)
+// are followed and exported alongside it.
+func (oe *v1Exporter) Export(ctx context.Context, store content.Store, desc ocispec.Descriptor, writer io.Writer) error {
+	manifests, err := oe.selectManifests(ctx, store, desc)
+	if err != nil {
+		return err
+	}
+	if len(manifests) == 0 {
+		return errors.Errorf("no manifest in %s matched the requested platforms", desc.Digest)
+	}
+
+	tw := tar.NewWriter(writer)
+	defer tw.Close()
+
+	layout := ocispec.ImageLayout{Version: ocispec.ImageLayoutVersion}
+	layoutBytes, err := json.Marshal(layout)
+	if err != nil {
+		return err
+	}
+	if err := writeTarEntry(tw, "oci-layout", layoutBytes); err != nil {
+		return err
+	}
+
+	seen := map[digest.Digest]struct{}{}
+	for _, m := range manifests {
+		if err := writeManifestBlobs(ctx, tw, store, m, seen); err != nil {
+			return err
+		}
+		if err := writeReferrers(ctx, tw, store, m, seen); err != nil {
+			return err
+		}
+	}
+
+	index := ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		Manifests: manifests,
+	}
+	indexBytes, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return writeTarEntry(tw, "index.json", indexBytes)
+}
+
+// selectManifests returns the manifests that should be listed in
+// index.json: desc itself if it is a single manifest matching the
+// configured platforms, or the matching children of desc if it is an
+// index or a Docker manifest list.
+func (oe *v1Exporter) selectManifests(ctx context.Context, store content.Store, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+	if !isIndexMediaType(desc.MediaType) {
+		if !matchesPlatform(desc.Platform, oe.platforms) {
+			return nil, nil
+		}
+		return []ocispec.Descriptor{desc}, nil
+	}
+
+	b, err := readBlob(ctx, store, desc)
+	if err != nil {
+		return nil, err
+	}
+	var idx ocispec.Index
+	if err := json.Unmarshal(b, &idx); err != nil {
+		return nil, err
+	}
+
+	var matched []ocispec.Descriptor
+	for _, m := range idx.Manifests {
+		if matchesPlatform(m.Platform, oe.platforms) {
+			matched = append(matched, m)
+		}
+	}
+	return matched, nil
+}
+
+// matchesPlatform reports whether p satisfies at least one of platforms.
+// An empty platforms list matches everything.
+func matchesPlatform(p *ocispec.Platform, platforms []ocispec.Platform) bool {
+	if len(platforms) == 0 {
+		return true
+	}
+	if p == nil {
+		return false
+	}
+	for _, want := range platforms {
+		if p.Architecture == want.Architecture && p.OS == want.OS && p.Variant == want.Variant {
+			return true
+		}
+	}
+	return false
+}
+
+// writeManifestBlobs recursively writes desc and everything it references
+// (index children, manifest config and layers) to tw, skipping blobs
+// already written. Only index/manifest bodies are buffered in memory to be
+// parsed for children; everything else (notably layers, which can be
+// multi-GB) is streamed straight from the content store.
+func writeManifestBlobs(ctx context.Context, tw *tar.Writer, store content.Store, desc ocispec.Descriptor, seen map[digest.Digest]struct{}) error {
+	if _, ok := seen[desc.Digest]; ok {
+		return nil
+	}
+	seen[desc.Digest] = struct{}{}
+
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageIndex, images.MediaTypeDockerSchema2ManifestList:
+		b, err := readBlob(ctx, store, desc)
+		if err != nil {
+			return err
+		}
+		if err := writeTarEntry(tw, blobPath(desc.Digest), b); err != nil {
+			return err
+		}
+		var idx ocispec.Index
+		if err := json.Unmarshal(b, &idx); err != nil {
+			return err
+		}
+		for _, m := range idx.Manifests {
+			if err := writeManifestBlobs(ctx, tw, store, m, seen); err != nil {
+				return err
+			}
+		}
+	case ocispec.MediaTypeImageManifest, images.MediaTypeDockerSchema2Manifest:
+		b, err := readBlob(ctx, store, desc)
+		if err != nil {
+			return err
+		}
+		if err := writeTarEntry(tw, blobPath(desc.Digest), b); err != nil {
+			return err
+		}
+		var man ocispec.Manifest
+		if err := json.Unmarshal(b, &man); err != nil {
+			return err
+		}
+		if err := writeManifestBlobs(ctx, tw, store, man.Config, seen); err != nil {
+			return err
+		}
+		for _, l := range man.Layers {
+			if err := writeManifestBlobs(ctx, tw, store, l, seen); err != nil {
+				return err
+			}
+		}
+	default:
+		if err := writeBlobStream(ctx, tw, store, desc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBlobStream writes desc's content to tw directly from store, without
+// buffering it into memory first.
+func writeBlobStream(ctx context.Context, tw *tar.Writer, store content.Provider, desc ocispec.Descriptor) error {
+	ra, err := store.ReaderAt(ctx, desc.Digest)
+	if err != nil {
+		return err
+	}
+	defer ra.Close()
+	if err := tw.WriteHeader(&tar.Header{
+		Name: blobPath(desc.Digest),
+		Mode: 0444,
+		Size: desc.Size,
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, io.NewSectionReader(ra, 0, desc.Size))
+	return err
+}
+
+// writeReferrers writes every manifest in store whose Subject points at
+// desc, and recurses so that a referrer's own referrers (e.g. a signature
+// on an SBOM) are exported too.
+func writeReferrers(ctx context.Context, tw *tar.Writer, store content.Store, desc ocispec.Descriptor, seen map[digest.Digest]struct{}) error {
+	referrers, err := Referrers(ctx, store, desc.Digest, "")
+	if err != nil {
+		return err
+	}
+	for _, r := range referrers {
+		if err := writeManifestBlobs(ctx, tw, store, r, seen); err != nil {
+			return err
+		}
+		if err := writeReferrers(ctx, tw, store, r, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isIndexMediaType(mt string) bool {
+	return mt == ocispec.MediaTypeImageIndex || mt == images.MediaTypeDockerSchema2ManifestList
+}
+
+func blobPath(dgst digest.Digest) string {
+	return "blobs/" + dgst.Algorithm().String() + "/" + dgst.Encoded()
+}
+
+func readBlob(ctx context.Context, store content.Provider, desc ocispec.Descriptor) ([]byte, error) {
+	ra, err := store.ReaderAt(ctx, desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	defer ra.Close()
+	b := make([]byte, desc.Size)
+	if _, err := io.ReadFull(io.NewSectionReader(ra, 0, desc.Size), b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func writeTarEntry(tw *tar.Writer, name string, b []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name: name,
+		Mode: 0444,
+		Size: int64(len(b)),
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(b)
+	return err
+}
@@ -0,0 +1,123 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/internal/contenttest"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestBlob commits b to store under its own digest and returns the
+// resulting descriptor.
+func writeTestBlob(ctx context.Context, t *testing.T, store content.Store, mediaType string, b []byte) ocispec.Descriptor {
+	t.Helper()
+	dgst := digest.FromBytes(b)
+	if err := content.WriteBlob(ctx, store, "test-"+dgst.String(), bytes.NewReader(b), int64(len(b)), dgst); err != nil {
+		t.Fatal(err)
+	}
+	return ocispec.Descriptor{MediaType: mediaType, Digest: dgst, Size: int64(len(b))}
+}
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	store := contenttest.NewStore()
+
+	layerDesc := writeTestBlob(ctx, t, store, ocispec.MediaTypeImageLayer, []byte("layer-contents"))
+
+	configBytes, err := json.Marshal(ocispec.Image{})
+	assert.Nil(t, err)
+	configDesc := writeTestBlob(ctx, t, store, ocispec.MediaTypeImageConfig, configBytes)
+
+	manifest := ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    configDesc,
+		Layers:    []ocispec.Descriptor{layerDesc},
+	}
+	manifestBytes, err := json.Marshal(manifest)
+	assert.Nil(t, err)
+	manifestDesc := writeTestBlob(ctx, t, store, ocispec.MediaTypeImageManifest, manifestBytes)
+
+	exporter, err := NewV1Exporter()
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	assert.Nil(t, exporter.Export(ctx, store, manifestDesc, &buf))
+
+	names := map[string]int64{}
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		names[hdr.Name] = hdr.Size
+	}
+
+	assert.Contains(t, names, "oci-layout")
+	assert.Contains(t, names, "index.json")
+	assert.Contains(t, names, blobPath(manifestDesc.Digest))
+	assert.Contains(t, names, blobPath(configDesc.Digest))
+	assert.Contains(t, names, blobPath(layerDesc.Digest))
+	assert.Equal(t, int64(len(manifestBytes)), names[blobPath(manifestDesc.Digest)])
+}
+
+func TestMatchesPlatform(t *testing.T) {
+	amd64Linux := &ocispec.Platform{Architecture: "amd64", OS: "linux"}
+	armLinuxV7 := &ocispec.Platform{Architecture: "arm", OS: "linux", Variant: "v7"}
+
+	testCases := []struct {
+		name      string
+		platform  *ocispec.Platform
+		platforms []ocispec.Platform
+		expected  bool
+	}{
+		{
+			name:     "no filter matches everything",
+			platform: amd64Linux,
+			expected: true,
+		},
+		{
+			name:     "no filter matches nil platform",
+			platform: nil,
+			expected: true,
+		},
+		{
+			name:      "matching platform",
+			platform:  amd64Linux,
+			platforms: []ocispec.Platform{{Architecture: "amd64", OS: "linux"}},
+			expected:  true,
+		},
+		{
+			name:      "non-matching platform",
+			platform:  amd64Linux,
+			platforms: []ocispec.Platform{{Architecture: "arm", OS: "linux", Variant: "v7"}},
+			expected:  false,
+		},
+		{
+			name:      "nil platform never matches a filter",
+			platform:  nil,
+			platforms: []ocispec.Platform{{Architecture: "amd64", OS: "linux"}},
+			expected:  false,
+		},
+		{
+			name:      "variant is part of the match",
+			platform:  armLinuxV7,
+			platforms: []ocispec.Platform{{Architecture: "arm", OS: "linux"}},
+			expected:  false,
+		},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, matchesPlatform(tc.platform, tc.platforms), tc.name)
+	}
+}
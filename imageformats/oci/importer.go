@@ -24,6 +24,11 @@ type v1Importer struct {
 
 // V1Importer implements OCI Image Spec v1.
 //
+// A manifest or index carrying a Subject descriptor (the referrers API used
+// for signatures, SBOMs and attestations) is imported like any other: the
+// referring manifest and all its blobs are stored regardless of filter, and
+// can later be enumerated with Referrers.
+//
 // Supported filters:
 // - index:        manifest list index (e.g. "0")
 // - tag:          org.opencontainers.image.ref.name tag (e.g. "latest")
@@ -32,11 +37,18 @@ type v1Importer struct {
 // - architecture: e.g. "amd64"
 // - os:           e.g. "linux"
 // - variant:      e.g. "v7" (when architecture is "arm")
+// - artifactType: e.g. "application/vnd.example.sbom.v1"
+// - subject:      digest of the manifest a referrer artifact attaches to
 var V1Importer imageformats.Importer = &v1Importer{}
 
-func (oi *v1Importer) Import(ctx context.Context, store content.Store, reader io.Reader, filter string) (*ocispec.Descriptor, error) {
+func (oi *v1Importer) Import(ctx context.Context, store content.Store, reader io.Reader, filter string, opts ...imageformats.ImportOpt) (*ocispec.Descriptor, error) {
+	var iopts imageformats.ImportOpts
+	for _, o := range opts {
+		o(&iopts)
+	}
+
 	tr := tar.NewReader(reader)
-	var desc *ocispec.Descriptor
+	var manifests []ocispec.Descriptor
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
@@ -49,25 +61,27 @@ func (oi *v1Importer) Import(ctx context.Context, store content.Store, reader io
 			continue
 		}
 		if hdr.Name == "index.json" {
-			desc, err = onUntarIndexJSON(tr, filter)
+			manifests, err = onUntarIndexJSON(tr)
 			if err != nil {
 				return nil, err
 			}
 			continue
 		}
 		if strings.HasPrefix(hdr.Name, "blobs/") {
-			if err := onUntarBlob(ctx, tr, store, hdr.Name, hdr.Size); err != nil {
+			if err := onUntarBlob(ctx, tr, store, hdr.Name, hdr.Size, iopts.Progress); err != nil {
 				return nil, err
 			}
 		}
 	}
-	if desc == nil {
-		return nil, errors.Errorf("no descriptor found for filter %q", filter)
+	if manifests == nil {
+		return nil, errors.Errorf("no index.json found in archive")
 	}
-	return desc, nil
+	// filtering on "subject" needs to read manifest blobs back from the
+	// store, so it only happens once the whole archive has been unpacked.
+	return filterManifests(ctx, store, manifests, filter)
 }
 
-func onUntarIndexJSON(r io.Reader, filter string) (*ocispec.Descriptor, error) {
+func onUntarIndexJSON(r io.Reader) ([]ocispec.Descriptor, error) {
 	b, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
@@ -76,10 +90,10 @@ func onUntarIndexJSON(r io.Reader, filter string) (*ocispec.Descriptor, error) {
 	if err := json.Unmarshal(b, &idx); err != nil {
 		return nil, err
 	}
-	return filterManifests(idx.Manifests, filter)
+	return idx.Manifests, nil
 }
 
-func onUntarBlob(ctx context.Context, r io.Reader, store content.Store, name string, size int64) error {
+func onUntarBlob(ctx context.Context, r io.Reader, store content.Store, name string, size int64, progress func(ocispec.Descriptor, int64, int64)) error {
 	// name is like "blobs/sha256/deadbeef"
 	split := strings.Split(name, "/")
 	if len(split) != 3 {
@@ -90,10 +104,51 @@ func onUntarBlob(ctx context.Context, r io.Reader, store content.Store, name str
 		return errors.Errorf("unsupported algorithm: %s", algo)
 	}
 	dgst := digest.NewDigestFromHex(algo.String(), split[2])
-	return content.WriteBlob(ctx, store, "unknown-"+dgst.String(), r, size, dgst)
+	desc := ocispec.Descriptor{Digest: dgst, Size: size}
+
+	// the blob's own path already names its expected digest, so asking
+	// for it by digest lets the store verify it on Commit and skip the
+	// copy entirely if it is already in the store.
+	cw, err := store.Writer(ctx, "unknown-"+dgst.String(), size, dgst)
+	if err != nil {
+		if errdefs.IsAlreadyExists(err) {
+			if progress != nil {
+				progress(desc, size, size)
+			}
+			return nil
+		}
+		return err
+	}
+	defer cw.Close()
+
+	w := io.Writer(cw)
+	if progress != nil {
+		w = &progressWriter{w: cw, desc: desc, total: size, report: progress}
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return err
+	}
+	return cw.Commit(ctx, size, dgst)
+}
+
+// progressWriter reports cumulative bytes written for desc after every
+// Write, so a caller can drive a progress bar for a single blob.
+type progressWriter struct {
+	w      io.Writer
+	desc   ocispec.Descriptor
+	offset int64
+	total  int64
+	report func(desc ocispec.Descriptor, offset, total int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.offset += int64(n)
+	p.report(p.desc, p.offset, p.total)
+	return n, err
 }
 
-func filterManifests(manifests []ocispec.Descriptor, filter string) (*ocispec.Descriptor, error) {
+func filterManifests(ctx context.Context, provider content.Provider, manifests []ocispec.Descriptor, filter string) (*ocispec.Descriptor, error) {
 	f, err := filters.Parse(filter)
 	if err != nil {
 		return nil, err
@@ -101,6 +156,8 @@ func filterManifests(manifests []ocispec.Descriptor, filter string) (*ocispec.De
 	var matched []ocispec.Descriptor
 	for i, m := range manifests {
 		adapter := imageAdapter{
+			ctx:      ctx,
+			provider: provider,
 			index:    i,
 			manifest: &m,
 		}
@@ -119,6 +176,8 @@ func filterManifests(manifests []ocispec.Descriptor, filter string) (*ocispec.De
 }
 
 type imageAdapter struct {
+	ctx      context.Context
+	provider content.Provider
 	index    int
 	manifest *ocispec.Descriptor
 }
@@ -152,6 +211,14 @@ func adaptImage(obj imageAdapter) filters.Adaptor {
 			if platform != nil && platform.Variant != "" {
 				return platform.Variant, true
 			}
+		case "artifactType":
+			return obj.manifest.ArtifactType, obj.manifest.ArtifactType != ""
+		case "subject":
+			subject, err := readSubject(obj.ctx, obj.provider, *obj.manifest)
+			if err != nil || subject == nil {
+				return "", false
+			}
+			return subject.Digest.String(), true
 		}
 		return "", false
 	})
@@ -0,0 +1,85 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/containerd/containerd/internal/contenttest"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+// buildOCILayout tars up a minimal OCI image layout with one manifest blob,
+// named by claimedDigest rather than the digest its actual bytes hash to, so
+// onUntarBlob's verification can be exercised with a controlled match or
+// mismatch.
+func buildOCILayout(t *testing.T, claimedDigest digest.Digest, manifestBytes []byte) []byte {
+	t.Helper()
+
+	idx := ocispec.Index{
+		Manifests: []ocispec.Descriptor{
+			{
+				MediaType: ocispec.MediaTypeImageManifest,
+				Digest:    claimedDigest,
+				Size:      int64(len(manifestBytes)),
+			},
+		},
+	}
+	idxBytes, err := json.Marshal(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	writeEntry := func(name string, b []byte) {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0444, Size: int64(len(b))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeEntry("index.json", idxBytes)
+	writeEntry(blobPath(claimedDigest), manifestBytes)
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImportRejectsMismatchedBlobDigest(t *testing.T) {
+	manifestBytes := []byte(`{"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+	wrongDigest := digest.FromBytes([]byte("not-the-manifest"))
+
+	archive := buildOCILayout(t, wrongDigest, manifestBytes)
+	store := contenttest.NewStore()
+	_, err := V1Importer.Import(context.Background(), store, bytes.NewReader(archive), "digest=="+wrongDigest.String())
+	assert.Error(t, err)
+}
+
+func TestImportIsIdempotent(t *testing.T) {
+	manifestBytes := []byte(`{"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+	dgst := digest.FromBytes(manifestBytes)
+
+	store := contenttest.NewStore()
+
+	archive := buildOCILayout(t, dgst, manifestBytes)
+	desc1, err := V1Importer.Import(context.Background(), store, bytes.NewReader(archive), "digest=="+dgst.String())
+	assert.Nil(t, err)
+
+	blobCount := store.Len()
+
+	// Re-importing the same archive should be a no-op: the blob it produces
+	// already exists under its expected digest, so the writer returns
+	// errdefs.ErrAlreadyExists and onUntarBlob skips the copy.
+	archive2 := buildOCILayout(t, dgst, manifestBytes)
+	desc2, err := V1Importer.Import(context.Background(), store, bytes.NewReader(archive2), "digest=="+dgst.String())
+	assert.Nil(t, err)
+	assert.Equal(t, desc1.Digest, desc2.Digest)
+	assert.Equal(t, blobCount, store.Len())
+}
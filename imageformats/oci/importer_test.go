@@ -1,9 +1,12 @@
 package oci
 
 import (
+	"context"
+	"encoding/json"
 	"testing"
 
 	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/internal/contenttest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -57,6 +60,14 @@ var exampleManifests = []ocispec.Descriptor{
 			Variant:      "v7",
 		},
 	},
+	{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		Digest:       "sha256:0000000000000000000000000000000000000000000000000000000000000004",
+		ArtifactType: "application/vnd.example.sbom.v1",
+		Annotations: map[string]string{
+			"test": "manifest4",
+		},
+	},
 }
 
 func TestImporterFilter(t *testing.T) {
@@ -109,9 +120,13 @@ func TestImporterFilter(t *testing.T) {
 			filter:      "",
 			expectedErr: errdefs.ErrAmbiguous,
 		},
+		{
+			filter:   "artifactType==application/vnd.example.sbom.v1",
+			expected: "manifest4",
+		},
 	}
 	for _, tc := range testCases {
-		filtered, err := filterManifests(exampleManifests, tc.filter)
+		filtered, err := filterManifests(context.Background(), nil, exampleManifests, tc.filter)
 		if tc.expectedErr != nil {
 			assert.Equal(t, tc.expectedErr, errors.Cause(err), tc.filter)
 		} else {
@@ -153,7 +168,7 @@ func TestImporterFilterWithSingleManifest(t *testing.T) {
 		},
 	}
 	for _, tc := range testCases {
-		filtered, err := filterManifests(x, tc.filter)
+		filtered, err := filterManifests(context.Background(), nil, x, tc.filter)
 
 		if tc.expectedErr != nil {
 			assert.Equal(t, tc.expectedErr, errors.Cause(err), tc.filter)
@@ -166,3 +181,32 @@ func TestImporterFilterWithSingleManifest(t *testing.T) {
 		}
 	}
 }
+
+func TestImporterFilterBySubject(t *testing.T) {
+	sbom := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Digest:    "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+		Annotations: map[string]string{
+			"test": "sbom",
+		},
+	}
+	sbomManifest := ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Subject: &ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageManifest,
+			Digest:    "sha256:772a956f8de2e8883b5b74dac1599eff29ee0beb09d02689a6d1805bf8487422",
+		},
+	}
+	sbomBytes, err := json.Marshal(sbomManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := contenttest.Provider{sbom.Digest: sbomBytes}
+	sbom.Size = int64(len(sbomBytes))
+
+	manifests := append([]ocispec.Descriptor{sbom}, exampleManifests...)
+	filtered, err := filterManifests(context.Background(), provider, manifests,
+		"subject==sha256:772a956f8de2e8883b5b74dac1599eff29ee0beb09d02689a6d1805bf8487422")
+	assert.Nil(t, err)
+	assert.Equal(t, "sbom", filtered.Annotations["test"])
+}
@@ -0,0 +1,82 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/containerd/containerd/content"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// subjectHeader is the subset of a manifest or index needed to resolve its
+// own Subject link, without committing to either type.
+type subjectHeader struct {
+	ArtifactType string              `json:"artifactType,omitempty"`
+	Subject      *ocispec.Descriptor `json:"subject,omitempty"`
+}
+
+// readSubject returns the Subject descriptor embedded in desc's manifest
+// or index body, or nil if it has none.
+func readSubject(ctx context.Context, provider content.Provider, desc ocispec.Descriptor) (*ocispec.Descriptor, error) {
+	b, err := readBlob(ctx, provider, desc)
+	if err != nil {
+		return nil, err
+	}
+	var hdr subjectHeader
+	if err := json.Unmarshal(b, &hdr); err != nil {
+		return nil, err
+	}
+	return hdr.Subject, nil
+}
+
+// maxReferrerCandidateSize bounds how large a Walk candidate in Referrers we
+// will buffer and probe for an embedded Subject. Manifests and indexes are
+// KB-sized JSON; anything bigger is a layer or other opaque blob and is
+// skipped unread, since reading it would mean fully buffering it for
+// nothing.
+const maxReferrerCandidateSize = 1 << 20 // 1MiB
+
+// Referrers enumerates the manifests in store whose Subject points at
+// subject, as left behind by V1Importer when it unpacks a referrers graph
+// (signatures, SBOMs, attestations attached to an image). When artifactType
+// is non-empty, only referrers with a matching ArtifactType are returned.
+func Referrers(ctx context.Context, store content.Store, subject digest.Digest, artifactType string) ([]ocispec.Descriptor, error) {
+	var referrers []ocispec.Descriptor
+	err := store.Walk(ctx, func(info content.Info) error {
+		if info.Size <= 0 || info.Size > maxReferrerCandidateSize {
+			// too big to be a manifest/index; not a candidate
+			return nil
+		}
+		desc := ocispec.Descriptor{Digest: info.Digest, Size: info.Size}
+		b, err := readBlob(ctx, store, desc)
+		if err != nil {
+			return err
+		}
+		var hdr struct {
+			MediaType string `json:"mediaType"`
+			subjectHeader
+		}
+		if err := json.Unmarshal(b, &hdr); err != nil {
+			// not JSON, or not a manifest/index (e.g. a layer blob)
+			return nil
+		}
+		if hdr.Subject == nil || hdr.Subject.Digest != subject {
+			return nil
+		}
+		if artifactType != "" && hdr.ArtifactType != artifactType {
+			return nil
+		}
+		referrers = append(referrers, ocispec.Descriptor{
+			MediaType:    hdr.MediaType,
+			ArtifactType: hdr.ArtifactType,
+			Digest:       info.Digest,
+			Size:         info.Size,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return referrers, nil
+}
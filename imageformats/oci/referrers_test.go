@@ -0,0 +1,69 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/internal/contenttest"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeReferrersTestBlob(ctx context.Context, t *testing.T, store content.Store, b []byte) digest.Digest {
+	t.Helper()
+	dgst := digest.FromBytes(b)
+	if err := content.WriteBlob(ctx, store, "test-"+dgst.String(), bytes.NewReader(b), int64(len(b)), dgst); err != nil {
+		t.Fatal(err)
+	}
+	return dgst
+}
+
+func TestReferrers(t *testing.T) {
+	ctx := context.Background()
+	store := contenttest.NewStore()
+
+	subjectBytes := []byte(`{"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+	subjectDigest := writeReferrersTestBlob(ctx, t, store, subjectBytes)
+
+	sbom := ocispec.Manifest{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.example.sbom.v1",
+		Subject:      &ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: subjectDigest},
+	}
+	sbomBytes, err := json.Marshal(sbom)
+	assert.Nil(t, err)
+	sbomDigest := writeReferrersTestBlob(ctx, t, store, sbomBytes)
+
+	signature := ocispec.Manifest{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.example.signature.v1",
+		Subject:      &ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: subjectDigest},
+	}
+	signatureBytes, err := json.Marshal(signature)
+	assert.Nil(t, err)
+	signatureDigest := writeReferrersTestBlob(ctx, t, store, signatureBytes)
+
+	// an unrelated blob with no Subject: must never show up as a referrer.
+	writeReferrersTestBlob(ctx, t, store, []byte("just some layer bytes"))
+
+	referrers, err := Referrers(ctx, store, subjectDigest, "")
+	assert.Nil(t, err)
+	var found []digest.Digest
+	for _, r := range referrers {
+		found = append(found, r.Digest)
+	}
+	assert.ElementsMatch(t, []digest.Digest{sbomDigest, signatureDigest}, found)
+
+	sbomOnly, err := Referrers(ctx, store, subjectDigest, "application/vnd.example.sbom.v1")
+	assert.Nil(t, err)
+	assert.Len(t, sbomOnly, 1)
+	assert.Equal(t, sbomDigest, sbomOnly[0].Digest)
+
+	none, err := Referrers(ctx, store, subjectDigest, "application/vnd.example.nonexistent.v1")
+	assert.Nil(t, err)
+	assert.Len(t, none, 0)
+}
@@ -0,0 +1,131 @@
+// Package contenttest provides in-memory content.Provider and content.Store
+// fakes shared by the imageformats importer/exporter tests, so each of them
+// doesn't need to hand-roll its own.
+package contenttest
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// readerAt implements content.ReaderAt over an in-memory blob.
+type readerAt struct {
+	*bytes.Reader
+}
+
+func (readerAt) Close() error { return nil }
+
+func (r readerAt) Size() int64 { return r.Reader.Size() }
+
+// Provider is a read-only content.Provider backed by a fixed set of blobs,
+// keyed by their own digest. It's enough to exercise code that only ever
+// reads blobs it already knows the digest of (filters, Subject lookups).
+type Provider map[digest.Digest][]byte
+
+func (p Provider) ReaderAt(ctx context.Context, dgst digest.Digest) (content.ReaderAt, error) {
+	b, ok := p[dgst]
+	if !ok {
+		return nil, errdefs.ErrNotFound
+	}
+	return readerAt{bytes.NewReader(b)}, nil
+}
+
+// Store is a minimal in-memory content.Store: enough to drive an Importer,
+// Exporter, or index.Create end-to-end in a test, including the
+// already-exists and digest-verification behavior a real content store
+// provides.
+type Store struct {
+	blobs map[digest.Digest][]byte
+}
+
+// NewStore returns an empty Store.
+func NewStore() *Store {
+	return &Store{blobs: map[digest.Digest][]byte{}}
+}
+
+// Len returns the number of distinct blobs committed to the store.
+func (s *Store) Len() int { return len(s.blobs) }
+
+// Blob returns the bytes committed under dgst, if any.
+func (s *Store) Blob(dgst digest.Digest) ([]byte, bool) {
+	b, ok := s.blobs[dgst]
+	return b, ok
+}
+
+func (s *Store) Info(ctx context.Context, dgst digest.Digest) (content.Info, error) {
+	b, ok := s.blobs[dgst]
+	if !ok {
+		return content.Info{}, errdefs.ErrNotFound
+	}
+	return content.Info{Digest: dgst, Size: int64(len(b))}, nil
+}
+
+func (s *Store) Walk(ctx context.Context, fn content.WalkFunc) error {
+	for dgst, b := range s.blobs {
+		if err := fn(content.Info{Digest: dgst, Size: int64(len(b))}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, dgst digest.Digest) error {
+	delete(s.blobs, dgst)
+	return nil
+}
+
+func (s *Store) Status(ctx context.Context, ref string) (content.Status, error) {
+	return content.Status{}, errdefs.ErrNotFound
+}
+
+func (s *Store) Abort(ctx context.Context, ref string) error { return nil }
+
+func (s *Store) Writer(ctx context.Context, ref string, size int64, expected digest.Digest) (content.Writer, error) {
+	if expected != "" {
+		if _, ok := s.blobs[expected]; ok {
+			return nil, errdefs.ErrAlreadyExists
+		}
+	}
+	return &writer{store: s, expected: expected}, nil
+}
+
+func (s *Store) ReaderAt(ctx context.Context, dgst digest.Digest) (content.ReaderAt, error) {
+	b, ok := s.blobs[dgst]
+	if !ok {
+		return nil, errdefs.ErrNotFound
+	}
+	return readerAt{bytes.NewReader(b)}, nil
+}
+
+// writer backs Store.Writer, buffering a single ingest in memory and
+// verifying it against the expected digest on Commit, the same check a real
+// content store performs.
+type writer struct {
+	store    *Store
+	buf      bytes.Buffer
+	expected digest.Digest
+}
+
+func (w *writer) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *writer) Close() error { return nil }
+
+func (w *writer) Digest() digest.Digest { return digest.FromBytes(w.buf.Bytes()) }
+
+func (w *writer) Status() (content.Status, error) { return content.Status{}, nil }
+
+func (w *writer) Truncate(size int64) error { w.buf.Reset(); return nil }
+
+func (w *writer) Commit(ctx context.Context, size int64, expected digest.Digest, opts ...content.Opt) error {
+	b := w.buf.Bytes()
+	dgst := digest.FromBytes(b)
+	if expected != "" && dgst != expected {
+		return errdefs.ErrFailedPrecondition
+	}
+	w.store.blobs[dgst] = append([]byte(nil), b...)
+	return nil
+}